@@ -0,0 +1,45 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	gethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// blockWaitPeriodSelector is the 4-byte selector for the BeefyLightClient
+// contract's public `BLOCK_WAIT_PERIOD` getter.
+var blockWaitPeriodSelector = crypto.Keccak256([]byte("BLOCK_WAIT_PERIOD()"))[:4]
+
+// QueryBeefyBlockDelay reads the BLOCK_WAIT_PERIOD slot from the
+// BeefyLightClient contract over the configured Ethereum endpoint, for
+// deployments that don't set `ethereum.beefy-block-delay` explicitly.
+func QueryBeefyBlockDelay(cfg *Config) (uint64, error) {
+	client, err := ethclient.Dial(cfg.Endpoint)
+	if err != nil {
+		return 0, fmt.Errorf("dial %s: %w", cfg.Endpoint, err)
+	}
+	defer client.Close()
+
+	contract := common.HexToAddress(cfg.Contracts.BeefyLightClient)
+
+	result, err := client.CallContract(context.Background(), gethereum.CallMsg{
+		To:   &contract,
+		Data: blockWaitPeriodSelector,
+	}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("call BLOCK_WAIT_PERIOD on %s: %w", contract.Hex(), err)
+	}
+	if len(result) == 0 {
+		return 0, fmt.Errorf("call BLOCK_WAIT_PERIOD on %s: no data returned, is 'ethereum.contracts.BeefyLightClient' set to the right address?", contract.Hex())
+	}
+
+	return new(big.Int).SetBytes(result).Uint64(), nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package ethereum
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Shutdown waits for the last transaction this chain's writer submitted
+// to be mined or to permanently fail, or for ctx to be cancelled,
+// whichever comes first, before stopping the chain. It satisfies
+// chain.Shutdowner, so Relay.Start prefers it over Stop.
+func (ch *Chain) Shutdown(ctx context.Context) error {
+	logger := log.WithField("chain", ch.Name())
+
+	err := ch.finality.Wait(ctx)
+	ch.Stop()
+	if err != nil {
+		return fmt.Errorf("wait for last submitted transaction to be mined: %w", err)
+	}
+
+	logger.Info("Last submitted transaction was mined, or none was pending")
+	return nil
+}
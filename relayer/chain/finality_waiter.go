@@ -0,0 +1,67 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"sync"
+)
+
+// FinalityWaiter tracks whether a chain writer has a submission in
+// flight, so Shutdown can wait for it to clear instead of assuming
+// there's nothing left to wait for. A Chain embeds one and calls
+// Submitting around each extrinsic or transaction it submits; Shutdown
+// then has something real to wait on, rather than Stopping the chain out
+// from under a submission that hasn't finalized yet.
+type FinalityWaiter struct {
+	mu       sync.Mutex
+	inFlight int
+	ch       chan struct{}
+}
+
+// Submitting marks a submission as in flight and returns a func the
+// caller must call exactly once, once that submission is finalized or
+// has permanently failed. Calling Submitting again before the previous
+// done fires extends the same wait rather than starting a second one.
+func (w *FinalityWaiter) Submitting() (done func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.inFlight == 0 {
+		w.ch = make(chan struct{})
+	}
+	w.inFlight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.inFlight--
+			if w.inFlight == 0 {
+				close(w.ch)
+			}
+		})
+	}
+}
+
+// Wait blocks until the in-flight submission, if any, is finalized, or
+// ctx is cancelled, whichever comes first. It returns immediately if
+// nothing is in flight.
+func (w *FinalityWaiter) Wait(ctx context.Context) error {
+	w.mu.Lock()
+	ch := w.ch
+	w.mu.Unlock()
+
+	if ch == nil {
+		return nil
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
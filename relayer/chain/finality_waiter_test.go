@@ -0,0 +1,93 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFinalityWaiterWaitReturnsImmediatelyWithNothingInFlight(t *testing.T) {
+	var w FinalityWaiter
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := w.Wait(ctx); err != nil {
+		t.Fatalf("expected Wait to return immediately, got %v", err)
+	}
+}
+
+func TestFinalityWaiterWaitBlocksUntilDone(t *testing.T) {
+	var w FinalityWaiter
+	done := w.Submitting()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- w.Wait(ctx) }()
+
+	select {
+	case err := <-waitDone:
+		t.Fatalf("expected Wait to still be blocked, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	done()
+
+	select {
+	case err := <-waitDone:
+		if err != nil {
+			t.Fatalf("expected Wait to succeed once done fired, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for Wait to return after done fired")
+	}
+}
+
+func TestFinalityWaiterWaitReturnsCtxErrorOnTimeout(t *testing.T) {
+	var w FinalityWaiter
+	defer w.Submitting()()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := w.Wait(ctx); err == nil {
+		t.Fatal("expected Wait to return an error once ctx was cancelled")
+	}
+}
+
+func TestFinalityWaiterSubmittingAgainStartsAFreshWait(t *testing.T) {
+	var w FinalityWaiter
+
+	firstDone := w.Submitting()
+	firstDone()
+
+	secondDone := w.Submitting()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- w.Wait(ctx) }()
+
+	select {
+	case err := <-waitDone:
+		t.Fatalf("expected the second submission's Wait to still be blocked, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	secondDone()
+
+	select {
+	case err := <-waitDone:
+		if err != nil {
+			t.Fatalf("expected Wait to succeed, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for Wait to return")
+	}
+}
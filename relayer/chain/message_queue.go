@@ -0,0 +1,117 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// PendingMessage pairs a Message with the ordering key used by
+// MessageQueue: the nonce of the account that submitted it, and the fee
+// it is willing to pay for inclusion.
+type PendingMessage struct {
+	Message      Message
+	Nonce        uint64
+	EffectiveFee uint64
+}
+
+// MessageQueue is a priority queue of pending messages, ordered by
+// sender nonce ascending and, within the same nonce, effective fee
+// descending. It is analogous to the transaction-prefetcher pattern in
+// go-ethereum's TransactionsByPriceAndNonce: writers Peek at the next
+// message before submitting it, and Forward lets them discard messages
+// that a chain reorg has already included, without ever panicking on an
+// empty queue.
+type MessageQueue struct {
+	mu    sync.Mutex
+	items pendingHeap
+}
+
+// NewMessageQueue returns an empty MessageQueue.
+func NewMessageQueue() *MessageQueue {
+	return &MessageQueue{}
+}
+
+// Push adds a pending message to the queue.
+func (q *MessageQueue) Push(msg PendingMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	heap.Push(&q.items, msg)
+}
+
+// Peek returns the highest-priority pending message without removing it.
+// The second return value is false if the queue is empty.
+func (q *MessageQueue) Peek() (PendingMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return PendingMessage{}, false
+	}
+	return q.items[0], true
+}
+
+// Shift removes and returns the highest-priority pending message. The
+// second return value is false if the queue was empty, so concurrent
+// callers draining the queue never panic.
+func (q *MessageQueue) Shift() (PendingMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return PendingMessage{}, false
+	}
+	msg := heap.Pop(&q.items).(PendingMessage)
+	return msg, true
+}
+
+// Forward discards every pending message with a nonce at or below the
+// given nonce. Callers use this after a reorg includes messages the
+// queue still holds, so the stale entries aren't resubmitted.
+func (q *MessageQueue) Forward(nonce uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	remaining := q.items[:0]
+	for _, item := range q.items {
+		if item.Nonce > nonce {
+			remaining = append(remaining, item)
+		}
+	}
+	q.items = remaining
+	heap.Init(&q.items)
+}
+
+// Len returns the number of pending messages in the queue.
+func (q *MessageQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// pendingHeap implements container/heap.Interface, ordering by nonce
+// ascending then effective fee descending.
+type pendingHeap []PendingMessage
+
+func (h pendingHeap) Len() int { return len(h) }
+
+func (h pendingHeap) Less(i, j int) bool {
+	if h[i].Nonce != h[j].Nonce {
+		return h[i].Nonce < h[j].Nonce
+	}
+	return h[i].EffectiveFee > h[j].EffectiveFee
+}
+
+func (h pendingHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *pendingHeap) Push(x interface{}) {
+	*h = append(*h, x.(PendingMessage))
+}
+
+func (h *pendingHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMessageQueueShiftOnEmptyQueueDoesNotPanic(t *testing.T) {
+	q := NewMessageQueue()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q.Shift()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, ok := q.Shift(); ok {
+		t.Fatal("expected empty queue to report no pending message")
+	}
+}
+
+func TestMessageQueueOrdersByNonceThenFee(t *testing.T) {
+	q := NewMessageQueue()
+	q.Push(PendingMessage{Nonce: 2, EffectiveFee: 10})
+	q.Push(PendingMessage{Nonce: 1, EffectiveFee: 5})
+	q.Push(PendingMessage{Nonce: 1, EffectiveFee: 50})
+
+	first, ok := q.Shift()
+	if !ok || first.Nonce != 1 || first.EffectiveFee != 50 {
+		t.Fatalf("expected nonce 1 fee 50 first, got %+v (ok=%v)", first, ok)
+	}
+
+	second, ok := q.Shift()
+	if !ok || second.Nonce != 1 || second.EffectiveFee != 5 {
+		t.Fatalf("expected nonce 1 fee 5 second, got %+v (ok=%v)", second, ok)
+	}
+
+	third, ok := q.Shift()
+	if !ok || third.Nonce != 2 {
+		t.Fatalf("expected nonce 2 third, got %+v (ok=%v)", third, ok)
+	}
+}
+
+func TestMessageQueueForwardDiscardsIncludedMessages(t *testing.T) {
+	q := NewMessageQueue()
+	q.Push(PendingMessage{Nonce: 1})
+	q.Push(PendingMessage{Nonce: 2})
+	q.Push(PendingMessage{Nonce: 3})
+
+	// A reorg re-included messages up to nonce 2; anything at or below
+	// that nonce is stale and must be discarded rather than resubmitted.
+	q.Forward(2)
+
+	if q.Len() != 1 {
+		t.Fatalf("expected 1 message remaining, got %d", q.Len())
+	}
+
+	msg, ok := q.Peek()
+	if !ok || msg.Nonce != 3 {
+		t.Fatalf("expected remaining message to have nonce 3, got %+v (ok=%v)", msg, ok)
+	}
+}
+
+func TestMessageQueueForwardOnEmptyQueue(t *testing.T) {
+	q := NewMessageQueue()
+	q.Forward(100)
+
+	if q.Len() != 0 {
+		t.Fatalf("expected empty queue to remain empty, got %d", q.Len())
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"time"
+)
+
+// priceNoncer is implemented by Message values that carry the sender
+// nonce and fee PriorityForwarder orders them by. Messages that don't
+// implement it are forwarded in arrival order, as if nonce and fee were
+// both zero.
+type priceNoncer interface {
+	Nonce() uint64
+	EffectiveFee() uint64
+}
+
+// PriorityForwarder sits between a chain's sender, which produces
+// batches of messages on In, and its counterpart's receiver, which reads
+// them one at a time off Out. It buffers arriving messages in a
+// MessageQueue so they're forwarded in sender-nonce order and, within a
+// nonce, highest-effective-fee-first, and so Forward can drop messages a
+// reorg has already included instead of letting them be resubmitted.
+//
+// The receiving chain writer is the one positioned to notice its own
+// reorgs, so it reports them by sending the highest now-included nonce
+// on Included rather than calling Forward directly; Run is what actually
+// applies it to the queue.
+type PriorityForwarder struct {
+	In       <-chan []Message
+	Out      chan<- []Message
+	Included <-chan uint64
+
+	queue *MessageQueue
+}
+
+// NewPriorityForwarder returns a PriorityForwarder that reorders
+// messages arriving on in before republishing them on out, discarding
+// any buffered message a reorg has already included whenever its nonce
+// arrives on included. included may be nil, in which case Forward is
+// only ever applied by a direct, e.g. test, call.
+func NewPriorityForwarder(in <-chan []Message, out chan<- []Message, included <-chan uint64) *PriorityForwarder {
+	return &PriorityForwarder{In: in, Out: out, Included: included, queue: NewMessageQueue()}
+}
+
+// Run pumps messages from In into the priority queue, applies Forward
+// for every nonce reported on Included, and forwards the
+// highest-priority pending message to Out as soon as there's room, until
+// In is closed or ctx is cancelled.
+func (f *PriorityForwarder) Run(ctx context.Context) error {
+	for {
+		pending, ok := f.queue.Peek()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case batch, ok := <-f.In:
+				if !ok {
+					return nil
+				}
+				f.enqueue(batch)
+			case nonce := <-f.Included:
+				f.Forward(nonce)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case batch, ok := <-f.In:
+			if !ok {
+				return nil
+			}
+			f.enqueue(batch)
+		case nonce := <-f.Included:
+			f.Forward(nonce)
+		case f.Out <- []Message{pending.Message}:
+			f.queue.Shift()
+		}
+	}
+}
+
+func (f *PriorityForwarder) enqueue(batch []Message) {
+	for _, msg := range batch {
+		nonce, fee := uint64(0), uint64(0)
+		if pn, ok := msg.(priceNoncer); ok {
+			nonce, fee = pn.Nonce(), pn.EffectiveFee()
+		}
+		f.queue.Push(PendingMessage{Message: msg, Nonce: nonce, EffectiveFee: fee})
+	}
+}
+
+// Forward discards every buffered message with a nonce at or below the
+// given nonce, so a reorg that already included them doesn't cause them
+// to be resubmitted.
+func (f *PriorityForwarder) Forward(nonce uint64) {
+	f.queue.Forward(nonce)
+}
+
+// Drain blocks until the queue is empty, meaning every message Run has
+// taken off In has been forwarded to Out or dropped by Forward, or ctx is
+// cancelled, whichever comes first. A batch still waiting in In's buffer
+// is not counted. Relay
+// shutdown uses this to give the downstream chain writer a chance to
+// submit whatever is still buffered before the process exits.
+func (f *PriorityForwarder) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if f.queue.Len() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
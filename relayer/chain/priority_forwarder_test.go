@@ -0,0 +1,154 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testMessage struct {
+	id           string
+	nonce        uint64
+	effectiveFee uint64
+}
+
+func (m testMessage) Nonce() uint64        { return m.nonce }
+func (m testMessage) EffectiveFee() uint64 { return m.effectiveFee }
+
+func TestPriorityForwarderForwardsInNonceThenFeeOrder(t *testing.T) {
+	in := make(chan []Message, 1)
+	out := make(chan []Message)
+	f := NewPriorityForwarder(in, out, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	in <- []Message{
+		testMessage{id: "a", nonce: 2, effectiveFee: 10},
+		testMessage{id: "b", nonce: 1, effectiveFee: 5},
+		testMessage{id: "c", nonce: 1, effectiveFee: 50},
+	}
+
+	wantOrder := []string{"c", "b", "a"}
+	for _, want := range wantOrder {
+		select {
+		case batch := <-out:
+			if len(batch) != 1 || batch[0].(testMessage).id != want {
+				t.Fatalf("expected message %q, got %+v", want, batch)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for message %q", want)
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func TestPriorityForwarderForwardDropsStaleMessages(t *testing.T) {
+	in := make(chan []Message, 1)
+	out := make(chan []Message)
+	f := NewPriorityForwarder(in, out, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	in <- []Message{
+		testMessage{id: "a", nonce: 1},
+		testMessage{id: "b", nonce: 2},
+	}
+
+	// Give Run a moment to pull the batch into the queue before a reorg
+	// report discards everything at or below nonce 1.
+	time.Sleep(10 * time.Millisecond)
+	f.Forward(1)
+
+	select {
+	case batch := <-out:
+		if len(batch) != 1 || batch[0].(testMessage).id != "b" {
+			t.Fatalf("expected only message %q to survive Forward, got %+v", "b", batch)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for surviving message")
+	}
+
+	cancel()
+	<-done
+}
+
+// TestPriorityForwarderRunAppliesReorgsReportedOnIncludedChannel exercises
+// the path a chain writer actually has in production: it has no access to
+// the PriorityForwarder itself, only the included channel SetReceiver was
+// given, so a reorg it detects is reported by sending a nonce there rather
+// than by calling Forward directly.
+func TestPriorityForwarderRunAppliesReorgsReportedOnIncludedChannel(t *testing.T) {
+	in := make(chan []Message, 1)
+	out := make(chan []Message)
+	included := make(chan uint64, 1)
+	f := NewPriorityForwarder(in, out, included)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	in <- []Message{
+		testMessage{id: "a", nonce: 1},
+		testMessage{id: "b", nonce: 2},
+	}
+
+	// Give Run a moment to pull the batch into the queue before the
+	// downstream chain writer reports that its own reorg already
+	// included everything up to and including nonce 1.
+	time.Sleep(10 * time.Millisecond)
+	included <- 1
+
+	select {
+	case batch := <-out:
+		if len(batch) != 1 || batch[0].(testMessage).id != "b" {
+			t.Fatalf("expected only message %q to survive the reorg report, got %+v", "b", batch)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for surviving message")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestPriorityForwarderDrainReturnsOnceQueueEmpty(t *testing.T) {
+	in := make(chan []Message, 1)
+	out := make(chan []Message, 1)
+	f := NewPriorityForwarder(in, out, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	in <- []Message{testMessage{id: "a", nonce: 1}}
+
+	if err := f.Drain(ctx); err != nil {
+		t.Fatalf("expected Drain to succeed, got %v", err)
+	}
+
+	select {
+	case <-out:
+	case <-ctx.Done():
+		t.Fatal("expected the drained message to have been forwarded")
+	}
+
+	cancel()
+	<-done
+}
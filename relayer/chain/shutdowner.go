@@ -0,0 +1,15 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package chain
+
+import "context"
+
+// Shutdowner is implemented by a Chain whose writer should wait for its
+// last submitted extrinsic or transaction to be finalized, rather than
+// being stopped immediately. Shutdown returns once that happens or ctx
+// is cancelled, whichever comes first. ethereum.Chain and substrate.Chain
+// both implement it by embedding a FinalityWaiter and waiting on it here.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
@@ -0,0 +1,28 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package substrate
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Shutdown waits for the last extrinsic this chain's writer submitted to
+// be finalized or to permanently fail, or for ctx to be cancelled,
+// whichever comes first, before stopping the chain. It satisfies
+// chain.Shutdowner, so Relay.Start prefers it over Stop.
+func (ch *Chain) Shutdown(ctx context.Context) error {
+	logger := log.WithField("chain", ch.Name())
+
+	err := ch.finality.Wait(ctx)
+	ch.Stop()
+	if err != nil {
+		return fmt.Errorf("wait for last submitted extrinsic to be finalized: %w", err)
+	}
+
+	logger.Info("Last submitted extrinsic was finalized, or none was pending")
+	return nil
+}
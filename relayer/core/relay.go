@@ -5,8 +5,10 @@ package core
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -17,35 +19,50 @@ import (
 	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
 
-	"github.com/snowfork/polkadot-ethereum/relayer/chain"
+	"github.com/ethereum/go-ethereum/common"
+
 	"github.com/snowfork/polkadot-ethereum/relayer/chain/ethereum"
 	"github.com/snowfork/polkadot-ethereum/relayer/chain/substrate"
 	"github.com/snowfork/polkadot-ethereum/relayer/store"
 )
 
 type Relay struct {
-	subChain chain.Chain
-	ethChain chain.Chain
-	database *store.Database
+	workers         []Worker
+	database        *store.Database
+	beefyMessages   chan store.DatabaseCmd
+	shutdownTimeout time.Duration
 }
 
-type Direction int
+type RelayConfig struct {
+	HeadersOnly     bool          `mapstructure:"headers-only"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout"`
+}
 
-const (
-	Bidirectional Direction = iota
-	EthToSub
-	SubToEth
-)
+// defaultShutdownTimeout bounds how long Relay.Start waits for workers
+// and the database to drain in-flight work before killing the process.
+const defaultShutdownTimeout = 30 * time.Second
 
-type RelayConfig struct {
-	Direction   Direction `mapstructure:"direction"`
-	HeadersOnly bool      `mapstructure:"headers-only"`
+// DatabaseConfig configures the store used to persist BEEFY commitments.
+type DatabaseConfig struct {
+	Dialect string `mapstructure:"dialect"`
+	DBPath  string `mapstructure:"db-path"`
 }
 
 type Config struct {
-	Relay RelayConfig      `mapstructure:"relay"`
-	Eth   ethereum.Config  `mapstructure:"ethereum"`
-	Sub   substrate.Config `mapstructure:"substrate"`
+	Workers  map[string]WorkerConfig `mapstructure:"workers"`
+	Relay    RelayConfig             `mapstructure:"relay"`
+	Database DatabaseConfig          `mapstructure:"database"`
+	Eth      ethereum.Config         `mapstructure:"ethereum"`
+	Sub      substrate.Config        `mapstructure:"substrate"`
+}
+
+// dbStoreConfig mirrors the JSON shape expected by
+// store.ParseConfigFromJson.
+type dbStoreConfig struct {
+	DBConfig struct {
+		Dialect string `json:"dialect"`
+		DBPath  string `json:"db_path"`
+	} `json:"db_config"`
 }
 
 func NewRelay() (*Relay, error) {
@@ -54,14 +71,15 @@ func NewRelay() (*Relay, error) {
 		return nil, err
 	}
 
-	// TODO: integrate with config
-	configJson := `
-	{"db_config": {
-			"dialect": "sqlite3",
-			"db_path": "./tmp.db"
-		}
-	}`
-	dbConfig := store.ParseConfigFromJson(configJson)
+	var storeConfig dbStoreConfig
+	storeConfig.DBConfig.Dialect = config.Database.Dialect
+	storeConfig.DBConfig.DBPath = config.Database.DBPath
+
+	configJson, err := json.Marshal(storeConfig)
+	if err != nil {
+		return nil, err
+	}
+	dbConfig := store.ParseConfigFromJson(string(configJson))
 
 	db, err := store.PrepareDatabase(dbConfig)
 	if err != nil {
@@ -72,64 +90,52 @@ func NewRelay() (*Relay, error) {
 	logger := log.WithField("database", "Beefy")
 	database := store.NewDatabase(db, beefyMessages, logger)
 
-	subChain, err := substrate.NewChain(&config.Sub)
+	// Built once and shared by every worker below: two workers wiring
+	// messages through independent ethereum.NewChain instances would mean
+	// two clients racing on the same config.Eth.PrivateKey's nonce.
+	ethChain, err := ethereum.NewChain(&config.Eth, database)
 	if err != nil {
 		return nil, err
 	}
-
-	ethChain, err := ethereum.NewChain(&config.Eth, database)
+	subChain, err := substrate.NewChain(&config.Sub)
 	if err != nil {
 		return nil, err
 	}
 
-	direction := config.Relay.Direction
-	headersOnly := config.Relay.HeadersOnly
-	if direction == Bidirectional || direction == EthToSub {
-		// channel for messages from ethereum
-		var ethMessages chan []chain.Message
-		if !headersOnly {
-			ethMessages = make(chan []chain.Message, 1)
-		}
-		// channel for headers from ethereum (it's a blocking channel so that we
-		// can guarantee that a header is forwarded before we send dependent messages)
-		ethHeaders := make(chan chain.Header)
-
-		err = subChain.SetReceiver(ethMessages, ethHeaders, beefyMessages)
-		if err != nil {
-			return nil, err
-		}
-		err = ethChain.SetSender(ethMessages, ethHeaders, beefyMessages)
-		if err != nil {
-			return nil, err
-		}
+	wCtx := &WorkerContext{
+		Config:        config,
+		Database:      database,
+		BeefyMessages: beefyMessages,
+		EthChain:      ethChain,
+		SubChain:      subChain,
 	}
 
-	if direction == Bidirectional || direction == SubToEth {
-		// channel for messages from substrate
-		var subMessages chan []chain.Message
-		if !headersOnly {
-			subMessages = make(chan []chain.Message, 1)
-		}
-
-		err := subChain.SetSender(subMessages, nil, beefyMessages)
-		if err != nil {
-			return nil, err
-		}
-		err = ethChain.SetReceiver(subMessages, nil, beefyMessages)
-		if err != nil {
-			return nil, err
-		}
+	workers, err := NewWorkerRegistry().Build(wCtx)
+	if err != nil {
+		return nil, err
 	}
 
+	// chainPairWorker goes first so the shared chains are up before any
+	// named worker wires messages through them, and so Relay.Start's
+	// reverse-startup-order shutdown tears them down last, after every
+	// named worker has drained whatever it queued.
+	workers = append([]Worker{newChainPairWorker(wCtx)}, workers...)
+
 	return &Relay{
-		subChain: subChain,
-		ethChain: ethChain,
-		database: database,
+		workers:         workers,
+		database:        database,
+		beefyMessages:   beefyMessages,
+		shutdownTimeout: config.Relay.ShutdownTimeout,
 	}, nil
 }
 
 func (re *Relay) Start() {
 
+	shutdownTimeout := re.shutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	eg, ctx := errgroup.WithContext(ctx)
 
@@ -160,32 +166,24 @@ func (re *Relay) Start() {
 	}
 	log.WithField("database", "Beefy").Info("Started database")
 
-	// Short-lived channels that communicate initialization parameters
-	// between the two chains. The chains close them after startup.
-	subInit := make(chan chain.Init, 1)
-	ethSubInit := make(chan chain.Init, 1)
-
-	err = re.ethChain.Start(ctx, eg, subInit, ethSubInit)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"chain": re.ethChain.Name(),
-			"error": err,
-		}).Error("Failed to start chain")
-		return
-	}
-	log.WithField("name", re.ethChain.Name()).Info("Started chain")
-	defer re.ethChain.Stop()
-
-	err = re.subChain.Start(ctx, eg, ethSubInit, subInit)
-	if err != nil {
-		log.WithFields(log.Fields{
-			"chain": re.subChain.Name(),
-			"error": err,
-		}).Error("Failed to start chain")
-		return
+	startedWorkers := make([]Worker, 0, len(re.workers))
+	for _, worker := range re.workers {
+		err := worker.Start(ctx, eg)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"worker": worker.Name(),
+				"error":  err,
+			}).Error("Failed to start worker")
+			// Plain Stop on whatever already started: there's no graceful
+			// shutdown context to wait on during a failed startup.
+			for i := len(startedWorkers) - 1; i >= 0; i-- {
+				startedWorkers[i].Stop()
+			}
+			return
+		}
+		log.WithField("name", worker.Name()).Info("Started worker")
+		startedWorkers = append(startedWorkers, worker)
 	}
-	log.WithField("name", re.subChain.Name()).Info("Started chain")
-	defer re.subChain.Stop()
 
 	notifyWaitDone := make(chan struct{})
 
@@ -200,31 +198,58 @@ func (re *Relay) Start() {
 	// Wait until a fatal error or signal is raised
 	select {
 	case <-notifyWaitDone:
-		break
+		return
 	case <-ctx.Done():
-		// Goroutines are either shutting down or deadlocked.
-		// Give them a few seconds...
-		select {
-		case <-time.After(3 * time.Second):
-			break
-		case _, stillWaiting := <-notifyWaitDone:
-			if !stillWaiting {
-				// All goroutines have ended
-				return
+	}
+
+	// Shut down in reverse-startup order, under a deadline, so in-flight
+	// BEEFY commitments and extrinsics get a chance to finalize instead
+	// of being lost.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	for i := len(re.workers) - 1; i >= 0; i-- {
+		worker := re.workers[i]
+		logger := log.WithField("worker", worker.Name())
+
+		if shutdowner, ok := worker.(Shutdowner); ok {
+			if err := shutdowner.Shutdown(shutdownCtx); err != nil {
+				logger.WithError(err).Error("Worker failed to shut down gracefully")
+				continue
 			}
+			logger.Info("Worker shut down gracefully")
+			continue
 		}
 
-		log.WithError(ctx.Err()).Error("Goroutines appear deadlocked. Killing process")
-		re.ethChain.Stop()
-		re.subChain.Stop()
-		// re.database.Stop() // TODO: graceful shutdown
+		worker.Stop()
+		logger.Info("Worker stopped")
+	}
 
-		relayProc, err := os.FindProcess(os.Getpid())
-		if err != nil {
-			log.WithError(err).Error("Failed to kill this process")
-		}
-		relayProc.Kill()
+	// Every worker above has stopped or drained, so nothing is left to
+	// produce a BEEFY command: closing here, rather than relying on
+	// Database.Shutdown to guess from a momentarily-empty channel, is what
+	// lets it tell "fully drained" apart from "empty right now".
+	close(re.beefyMessages)
+
+	if err := re.database.Shutdown(shutdownCtx); err != nil {
+		log.WithField("database", "Beefy").WithError(err).Error("Database failed to shut down gracefully")
+	} else {
+		log.WithField("database", "Beefy").Info("Database shut down gracefully")
 	}
+
+	select {
+	case <-notifyWaitDone:
+		return
+	case <-shutdownCtx.Done():
+	}
+
+	log.WithError(shutdownCtx.Err()).Error("Shutdown deadline exceeded. Killing process")
+
+	relayProc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		log.WithError(err).Error("Failed to kill this process")
+	}
+	relayProc.Kill()
 }
 
 func LoadConfig() (*Config, error) {
@@ -234,13 +259,6 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	var direction = config.Relay.Direction
-	if direction != Bidirectional &&
-		direction != EthToSub &&
-		direction != SubToEth {
-		return nil, fmt.Errorf("'direction' has invalid value %d", direction)
-	}
-
 	// Load secrets from environment variables
 	var value string
 	var ok bool
@@ -251,20 +269,85 @@ func LoadConfig() (*Config, error) {
 	}
 	config.Eth.PrivateKey = strings.TrimPrefix(value, "0x")
 
-	// TODO: auto populate contract addresses
-	config.Eth.Contracts.PolkadotRelayChainBridge = "0x8cF6147918A5CBb672703F879f385036f8793a24"
-	config.Eth.Contracts.ValidatorRegistry = "0xB1185EDE04202fE62D38F5db72F71e38Ff3E8305"
-	// TODO: query from 'BLOCK_WAIT_PERIOD' on RelayBridgeLightClient contract
-	config.Eth.BeefyBlockDelay = 5
+	if err := validateEthereumContracts(&config.Eth); err != nil {
+		return nil, err
+	}
+
+	if config.Eth.BeefyBlockDelay == 0 {
+		delay, err := ethereum.QueryBeefyBlockDelay(&config.Eth)
+		if err != nil {
+			return nil, fmt.Errorf("'ethereum.beefy-block-delay' not set and could not be queried from the chain: %w", err)
+		}
+		config.Eth.BeefyBlockDelay = delay
+	}
+	if config.Eth.BeefyBlockDelay == 0 {
+		return nil, fmt.Errorf("'ethereum.beefy-block-delay' resolved to 0, which would disable the BEEFY dispute-period wait entirely")
+	}
 
 	value, ok = os.LookupEnv("ARTEMIS_SUBSTRATE_KEY")
 	if !ok {
 		return nil, fmt.Errorf("environment variable not set: ARTEMIS_SUBSTRATE_KEY")
 	}
 	config.Sub.Parachain.PrivateKey = value
-	config.Sub.Parachain.Endpoint = "ws://127.0.0.1:11144"
-	config.Sub.Relaychain.Endpoint = "ws://127.0.0.1:9944"
-	config.Sub.Relaychain.PrivateKey = "//Alice" // TODO: proper configuration
+
+	if config.Sub.Relaychain.PrivateKey == "" {
+		// Dev-only default, matching the local relaychain dev chain's
+		// well-known Alice key.
+		config.Sub.Relaychain.PrivateKey = "//Alice"
+	}
+
+	if err := validateWSEndpoint("substrate.parachain.endpoint", config.Sub.Parachain.Endpoint); err != nil {
+		return nil, err
+	}
+	if err := validateWSEndpoint("substrate.relaychain.endpoint", config.Sub.Relaychain.Endpoint); err != nil {
+		return nil, err
+	}
+
+	if config.Database.Dialect == "" {
+		config.Database.Dialect = "sqlite3"
+	}
+	if config.Database.DBPath == "" {
+		config.Database.DBPath = "./tmp.db"
+	}
 
 	return &config, nil
 }
+
+// validateEthereumContracts checks that the configured contract addresses
+// are valid, checksummed addresses. It doesn't check the BEEFY block
+// delay for sanity: that isn't resolved from the chain until after this
+// runs, so LoadConfig validates it separately, once BeefyBlockDelay has
+// its final value.
+func validateEthereumContracts(cfg *ethereum.Config) error {
+	if err := validateChecksummedAddress("ethereum.contracts.PolkadotRelayChainBridge", cfg.Contracts.PolkadotRelayChainBridge); err != nil {
+		return err
+	}
+	if err := validateChecksummedAddress("ethereum.contracts.ValidatorRegistry", cfg.Contracts.ValidatorRegistry); err != nil {
+		return err
+	}
+	if err := validateChecksummedAddress("ethereum.contracts.BeefyLightClient", cfg.Contracts.BeefyLightClient); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateChecksummedAddress(field, address string) error {
+	if !common.IsHexAddress(address) {
+		return fmt.Errorf("'%s' is not a valid address: %q", field, address)
+	}
+	if address != common.HexToAddress(address).Hex() {
+		return fmt.Errorf("'%s' is not a checksummed address: %q", field, address)
+	}
+	return nil
+}
+
+func validateWSEndpoint(field, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("'%s' is not a valid URL: %w", field, err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("'%s' must be a 'ws://' or 'wss://' URL, got %q", field, endpoint)
+	}
+	return nil
+}
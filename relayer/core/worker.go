@@ -0,0 +1,152 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/relayer/chain"
+	"github.com/snowfork/polkadot-ethereum/relayer/store"
+)
+
+// WorkerContext bundles the resources shared by every worker: the config
+// the worker was started from, the shared database connection, the
+// channel used to persist BEEFY commitments, and the single Ethereum and
+// substrate chain instances every worker wires its messages through.
+//
+// EthChain and SubChain are constructed once, by NewRelay, and shared by
+// every enabled worker. Workers must never construct their own chain
+// instances: two independent clients against the same
+// ethereum.Config.PrivateKey would race on the same account's nonce.
+type WorkerContext struct {
+	Config        *Config
+	Database      *store.Database
+	BeefyMessages chan store.DatabaseCmd
+	EthChain      chain.Chain
+	SubChain      chain.Chain
+}
+
+// Worker is an independently configurable relayer role, e.g. relaying
+// Ethereum headers to a parachain or relaying BEEFY commitments to
+// Ethereum. Workers are started and stopped under the Relay's errgroup.
+type Worker interface {
+	Name() string
+	Start(ctx context.Context, eg *errgroup.Group) error
+	Stop()
+}
+
+// Shutdowner is implemented by workers and stores that can wait for
+// in-flight work (a submitted extrinsic or transaction, a queued database
+// write) to finish before the process exits, rather than being torn down
+// immediately by Stop. Relay.Start prefers Shutdown over Stop wherever a
+// component implements it.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// WorkerConfig is the per-worker subconfig read from the `[workers]`
+// section of the relayer config, e.g.:
+//
+//	[workers.beefy-relayer]
+//	enabled = true
+type WorkerConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// WorkerFactory builds a named Worker from shared context. It only fails
+// if construction itself fails; whether the worker actually runs is
+// decided by its WorkerConfig.Enabled flag.
+type WorkerFactory func(wCtx *WorkerContext) (Worker, error)
+
+// workerRegistration pairs a worker's name with its factory. Keeping
+// registrations in a slice, rather than a map, means Build starts workers
+// in a fixed order every run, which in turn makes Relay.Start's
+// reverse-startup-order shutdown deterministic.
+type workerRegistration struct {
+	name    string
+	factory WorkerFactory
+}
+
+// WorkerRegistry is the ordered set of worker factories known to the
+// relay, keyed by the name used in config and in logs.
+type WorkerRegistry []workerRegistration
+
+// NewWorkerRegistry returns the registry of every bridging role this
+// binary knows how to run. Adding a new role means adding an entry here,
+// not editing NewRelay.
+func NewWorkerRegistry() WorkerRegistry {
+	return WorkerRegistry{
+		{"ethereum-header-relayer", NewEthereumHeaderRelayerWorker},
+		{"substrate-header-relayer", NewSubstrateHeaderRelayerWorker},
+		{"parachain-commitment-relayer", NewParachainCommitmentRelayerWorker},
+		{"beefy-relayer", NewBeefyRelayerWorker},
+	}
+}
+
+// conflictingWorkerPairs lists worker names that must never both be
+// enabled: each pair's factories call SetSender/SetReceiver for the same
+// role (sender, receiver, or both) on the same shared EthChain/SubChain,
+// so enabling both lets whichever factory runs second silently clobber
+// the first's wiring with no error.
+var conflictingWorkerPairs = [][2]string{
+	{"ethereum-header-relayer", "beefy-relayer"},
+	{"substrate-header-relayer", "parachain-commitment-relayer"},
+}
+
+// validateWorkerCombination rejects any combination of enabled workers
+// that would race to wire the same chain role, checked before Build
+// constructs any worker so a misconfiguration fails loudly at startup
+// instead of silently clobbering wiring at runtime.
+func validateWorkerCombination(configs map[string]WorkerConfig) error {
+	enabled := func(name string) bool {
+		cfg, ok := configs[name]
+		return ok && cfg.Enabled
+	}
+
+	for _, pair := range conflictingWorkerPairs {
+		if enabled(pair[0]) && enabled(pair[1]) {
+			return fmt.Errorf("'%s' and '%s' cannot both be enabled: they wire conflicting roles onto the same shared chain connection", pair[0], pair[1])
+		}
+	}
+	return nil
+}
+
+// Build instantiates every worker enabled in config, in registration
+// order, looking each one up by name in the registry.
+func (wr WorkerRegistry) Build(wCtx *WorkerContext) ([]Worker, error) {
+	if err := validateWorkerCombination(wCtx.Config.Workers); err != nil {
+		return nil, err
+	}
+
+	var workers []Worker
+	for _, reg := range wr {
+		workerConfig, ok := wCtx.Config.Workers[reg.name]
+		if !ok || !workerConfig.Enabled {
+			continue
+		}
+
+		worker, err := reg.factory(wCtx)
+		if err != nil {
+			return nil, fmt.Errorf("create worker '%s': %w", reg.name, err)
+		}
+		workers = append(workers, worker)
+	}
+
+	if len(workers) == 0 {
+		return nil, fmt.Errorf("no workers enabled: set `[workers.<name>] enabled = true` for at least one of %v", wr.names())
+	}
+
+	return workers, nil
+}
+
+func (wr WorkerRegistry) names() []string {
+	names := make([]string, len(wr))
+	for i, reg := range wr {
+		names[i] = reg.name
+	}
+	return names
+}
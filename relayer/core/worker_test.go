@@ -0,0 +1,105 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import "testing"
+
+func TestValidateWorkerCombinationRejectsConflictingPairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs map[string]WorkerConfig
+		wantErr bool
+	}{
+		{
+			name: "ethereum-header-relayer and beefy-relayer both enabled",
+			configs: map[string]WorkerConfig{
+				"ethereum-header-relayer": {Enabled: true},
+				"beefy-relayer":           {Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "substrate-header-relayer and parachain-commitment-relayer both enabled",
+			configs: map[string]WorkerConfig{
+				"substrate-header-relayer":     {Enabled: true},
+				"parachain-commitment-relayer": {Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ethereum-header-relayer alone",
+			configs: map[string]WorkerConfig{
+				"ethereum-header-relayer": {Enabled: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "one side of a conflicting pair disabled",
+			configs: map[string]WorkerConfig{
+				"ethereum-header-relayer": {Enabled: true},
+				"beefy-relayer":           {Enabled: false},
+			},
+			wantErr: false,
+		},
+		{
+			name: "both non-conflicting pairs enabled together",
+			configs: map[string]WorkerConfig{
+				"ethereum-header-relayer":  {Enabled: true},
+				"substrate-header-relayer": {Enabled: true},
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWorkerCombination(tt.configs)
+			if tt.wantErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWorkerRegistryNamesAreInRegistrationOrder(t *testing.T) {
+	want := []string{
+		"ethereum-header-relayer",
+		"substrate-header-relayer",
+		"parachain-commitment-relayer",
+		"beefy-relayer",
+	}
+
+	got := NewWorkerRegistry().names()
+	if len(got) != len(want) {
+		t.Fatalf("got %d names, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("name %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+// TestWorkerRegistryBuildRejectsConflictingCombinationBeforeConstructingAnyWorker
+// checks that Build fails on a conflicting combination before it ever
+// calls a factory. wCtx.EthChain/SubChain are left nil, so a factory call
+// would panic immediately - if Build reached one, this test would fail
+// with a panic rather than a clean error.
+func TestWorkerRegistryBuildRejectsConflictingCombinationBeforeConstructingAnyWorker(t *testing.T) {
+	wCtx := &WorkerContext{
+		Config: &Config{
+			Workers: map[string]WorkerConfig{
+				"ethereum-header-relayer": {Enabled: true},
+				"beefy-relayer":           {Enabled: true},
+			},
+		},
+	}
+
+	if _, err := NewWorkerRegistry().Build(wCtx); err == nil {
+		t.Fatal("expected Build to reject a conflicting worker combination")
+	}
+}
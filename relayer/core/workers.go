@@ -0,0 +1,224 @@
+// Copyright 2020 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/snowfork/polkadot-ethereum/relayer/chain"
+)
+
+// namedChain pairs a chain.Chain with the short-lived init channels it
+// exchanges with its counterpart at startup.
+type namedChain struct {
+	name    string
+	chain   chain.Chain
+	initIn  chan chain.Init
+	initOut chan chain.Init
+}
+
+// chainPairWorker starts and stops the Ethereum and substrate chains
+// shared by every enabled worker. It is always present, ahead of every
+// named worker, so the shared chains are up before any worker wires
+// messages through them, and so Relay.Start's reverse-startup-order
+// shutdown tears them down last.
+type chainPairWorker struct {
+	chains []namedChain
+}
+
+// newChainPairWorker wraps the shared Ethereum and substrate chains, and
+// the init channels they exchange at startup, as a Worker.
+func newChainPairWorker(wCtx *WorkerContext) *chainPairWorker {
+	ethChain, subChain := wCtx.EthChain, wCtx.SubChain
+
+	subInit := make(chan chain.Init, 1)
+	ethSubInit := make(chan chain.Init, 1)
+
+	return &chainPairWorker{
+		chains: []namedChain{
+			{name: ethChain.Name(), chain: ethChain, initIn: subInit, initOut: ethSubInit},
+			{name: subChain.Name(), chain: subChain, initIn: ethSubInit, initOut: subInit},
+		},
+	}
+}
+
+func (w *chainPairWorker) Name() string { return "chains" }
+
+func (w *chainPairWorker) Start(ctx context.Context, eg *errgroup.Group) error {
+	for _, c := range w.chains {
+		if err := c.chain.Start(ctx, eg, c.initIn, c.initOut); err != nil {
+			return fmt.Errorf("start %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+func (w *chainPairWorker) Stop() {
+	for _, c := range w.chains {
+		c.chain.Stop()
+	}
+}
+
+// Shutdown stops each chain, preferring chain.Shutdowner where a chain
+// implements it so its last submitted extrinsic or transaction has a
+// chance to finalize instead of being abandoned mid-flight. Relay.Start
+// calls this only after every messageRouteWorker.Shutdown has returned,
+// so nothing is still queued up for either chain to pick up by the time
+// it runs.
+func (w *chainPairWorker) Shutdown(ctx context.Context) error {
+	var firstErr error
+	for _, c := range w.chains {
+		if shutdowner, ok := c.chain.(chain.Shutdowner); ok {
+			if err := shutdowner.Shutdown(ctx); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("shut down %s: %w", c.name, err)
+			}
+			continue
+		}
+		c.chain.Stop()
+	}
+	return firstErr
+}
+
+// messageRouteWorker represents one named bridging role's wiring onto
+// the shared chain pair. Where the role forwards messages (rather than
+// headers alone), it also owns the goroutine that pumps its
+// chain.PriorityForwarder, which is what actually pushes, peeks and
+// shifts the underlying MessageQueue; Start is a no-op for roles with no
+// forwarder, since the shared chainPairWorker owns the chains'
+// lifecycle.
+type messageRouteWorker struct {
+	name      string
+	forwarder *chain.PriorityForwarder
+}
+
+func (w *messageRouteWorker) Name() string { return w.name }
+
+func (w *messageRouteWorker) Start(ctx context.Context, eg *errgroup.Group) error {
+	if w.forwarder == nil {
+		return nil
+	}
+	eg.Go(func() error {
+		return w.forwarder.Run(ctx)
+	})
+	return nil
+}
+
+func (w *messageRouteWorker) Stop() {}
+
+// Shutdown waits for this role's forwarder, if any, to hand off every
+// buffered message to its chain writer, or for ctx to be cancelled,
+// whichever comes first. Relay.Start calls this on every worker before
+// chainPairWorker.Shutdown, so the chains aren't stopped out from under
+// messages that were still queued.
+func (w *messageRouteWorker) Shutdown(ctx context.Context) error {
+	if w.forwarder == nil {
+		return nil
+	}
+	return w.forwarder.Drain(ctx)
+}
+
+// wireMessages connects a sender and receiver through a priority-ordered
+// chain.PriorityForwarder, so the writer on the receiving end always
+// gets its next message via Peek/Shift in nonce/fee order and has a
+// channel of its own to report a reorg back on, or directly with nil
+// channels when the relay is running headers-only and has no messages to
+// forward at all.
+func wireMessages(wCtx *WorkerContext, setSender func(chan []chain.Message) error, setReceiver func(chan []chain.Message, chan<- uint64) error) (*chain.PriorityForwarder, error) {
+	if wCtx.Config.Relay.HeadersOnly {
+		if err := setSender(nil); err != nil {
+			return nil, err
+		}
+		if err := setReceiver(nil, nil); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	out := make(chan []chain.Message, 1)
+	in := make(chan []chain.Message, 1)
+	included := make(chan uint64, 1)
+	forwarder := chain.NewPriorityForwarder(out, in, included)
+
+	if err := setSender(out); err != nil {
+		return nil, err
+	}
+	if err := setReceiver(in, included); err != nil {
+		return nil, err
+	}
+
+	return forwarder, nil
+}
+
+// NewEthereumHeaderRelayerWorker relays Ethereum headers, and the
+// messages that depend on them, to the parachain.
+func NewEthereumHeaderRelayerWorker(wCtx *WorkerContext) (Worker, error) {
+	ethChain, subChain := wCtx.EthChain, wCtx.SubChain
+
+	// Blocking channel so that a header is always forwarded before any
+	// message that depends on it.
+	ethHeaders := make(chan chain.Header)
+
+	forwarder, err := wireMessages(wCtx,
+		func(out chan []chain.Message) error { return ethChain.SetSender(out, ethHeaders, wCtx.BeefyMessages) },
+		func(in chan []chain.Message, included chan<- uint64) error {
+			return subChain.SetReceiver(in, ethHeaders, wCtx.BeefyMessages, included)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messageRouteWorker{name: "ethereum-header-relayer", forwarder: forwarder}, nil
+}
+
+// NewParachainCommitmentRelayerWorker relays parachain message
+// commitments (and the relaychain headers they depend on) to Ethereum.
+func NewParachainCommitmentRelayerWorker(wCtx *WorkerContext) (Worker, error) {
+	ethChain, subChain := wCtx.EthChain, wCtx.SubChain
+
+	forwarder, err := wireMessages(wCtx,
+		func(out chan []chain.Message) error { return subChain.SetSender(out, nil, wCtx.BeefyMessages) },
+		func(in chan []chain.Message, included chan<- uint64) error {
+			return ethChain.SetReceiver(in, nil, wCtx.BeefyMessages, included)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messageRouteWorker{name: "parachain-commitment-relayer", forwarder: forwarder}, nil
+}
+
+// NewSubstrateHeaderRelayerWorker relays relaychain headers to Ethereum,
+// independently of any parachain message commitments.
+func NewSubstrateHeaderRelayerWorker(wCtx *WorkerContext) (Worker, error) {
+	ethChain, subChain := wCtx.EthChain, wCtx.SubChain
+
+	if err := subChain.SetSender(nil, nil, wCtx.BeefyMessages); err != nil {
+		return nil, err
+	}
+	if err := ethChain.SetReceiver(nil, nil, wCtx.BeefyMessages, nil); err != nil {
+		return nil, err
+	}
+
+	return &messageRouteWorker{name: "substrate-header-relayer"}, nil
+}
+
+// NewBeefyRelayerWorker relays BEEFY commitments from the relaychain to
+// Ethereum, independently of any other header or message relaying.
+func NewBeefyRelayerWorker(wCtx *WorkerContext) (Worker, error) {
+	ethChain, subChain := wCtx.EthChain, wCtx.SubChain
+
+	if err := subChain.SetReceiver(nil, nil, wCtx.BeefyMessages, nil); err != nil {
+		return nil, err
+	}
+	if err := ethChain.SetSender(nil, nil, wCtx.BeefyMessages); err != nil {
+		return nil, err
+	}
+
+	return &messageRouteWorker{name: "beefy-relayer"}, nil
+}
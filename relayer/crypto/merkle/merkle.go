@@ -0,0 +1,155 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+// Package merkle builds the binary Merkle tree used to prove inclusion
+// of a single leaf (e.g. a parachain header) among a committed set.
+package merkle
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type hash = [32]byte
+
+// parallelThreshold is the minimum width of a layer before it is hashed
+// across a worker pool rather than serially. Below it, goroutine
+// scheduling overhead outweighs any speedup.
+const parallelThreshold = 64
+
+// GenerateMerkleProof hashes each pre-leaf with Keccak256, builds a
+// binary Merkle tree over the resulting leaves, and returns the leaf,
+// root and proof for the leaf at proveIndex. A layer of odd width is
+// completed by duplicating its last node.
+func GenerateMerkleProof(preLeaves [][]byte, proveIndex int64) ([]byte, []byte, [][32]byte, error) {
+	if len(preLeaves) == 0 {
+		return nil, nil, nil, fmt.Errorf("cannot build a merkle tree with no leaves")
+	}
+	if proveIndex < 0 || proveIndex >= int64(len(preLeaves)) {
+		return nil, nil, nil, fmt.Errorf("proveIndex %d out of range for %d leaves", proveIndex, len(preLeaves))
+	}
+
+	leaves := hashLeaves(preLeaves)
+	layers := buildLayers(leaves)
+
+	root := layers[len(layers)-1][0]
+	leaf := leaves[proveIndex]
+	proof := collectProof(layers, proveIndex)
+
+	return leaf[:], root[:], proof, nil
+}
+
+func hashLeaves(preLeaves [][]byte) []hash {
+	leaves := make([]hash, len(preLeaves))
+
+	if len(preLeaves) < parallelThreshold {
+		for i, preLeaf := range preLeaves {
+			leaves[i] = crypto.Keccak256Hash(preLeaf)
+		}
+		return leaves
+	}
+
+	parallelFor(len(preLeaves), func(i int) {
+		leaves[i] = crypto.Keccak256Hash(preLeaves[i])
+	})
+
+	return leaves
+}
+
+// buildLayers constructs every layer of the tree, from the leaves up to
+// the single-node root layer.
+func buildLayers(leaves []hash) [][]hash {
+	layers := [][]hash{leaves}
+
+	layer := leaves
+	for len(layer) > 1 {
+		next := hashLayer(layer)
+		layers = append(layers, next)
+		layer = next
+	}
+
+	return layers
+}
+
+func hashLayer(layer []hash) []hash {
+	width := len(layer)
+	pairs := (width + 1) / 2
+	next := make([]hash, pairs)
+
+	hashPair := func(i int) {
+		left := layer[2*i]
+		right := left
+		if 2*i+1 < width {
+			right = layer[2*i+1]
+		}
+		next[i] = crypto.Keccak256Hash(append(left[:], right[:]...))
+	}
+
+	if pairs < parallelThreshold {
+		for i := 0; i < pairs; i++ {
+			hashPair(i)
+		}
+		return next
+	}
+
+	parallelFor(pairs, hashPair)
+	return next
+}
+
+// collectProof walks from the leaf at index up to the root, collecting
+// the sibling at each layer.
+func collectProof(layers [][]hash, index int64) [][32]byte {
+	proof := make([][32]byte, 0, len(layers)-1)
+
+	i := index
+	for _, layer := range layers[:len(layers)-1] {
+		sibling := i + 1
+		if i%2 != 0 {
+			sibling = i - 1
+		}
+		if int(sibling) >= len(layer) {
+			// Odd layer: this node was paired with itself.
+			sibling = i
+		}
+		proof = append(proof, layer[sibling])
+
+		i = i / 2
+	}
+
+	return proof
+}
+
+// parallelFor runs fn(i) for i in [0, n) across a worker pool bounded by
+// GOMAXPROCS, blocking until every call has returned.
+func parallelFor(n int, fn func(i int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > n {
+		workers = n
+	}
+
+	chunk := (n + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * chunk
+		if start >= n {
+			break
+		}
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				fn(i)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+}
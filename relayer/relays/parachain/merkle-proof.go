@@ -3,7 +3,9 @@ package parachain
 import (
 	"encoding/hex"
 	"encoding/json"
+	"runtime"
 	"sort"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/snowfork/go-substrate-rpc-client/v3/types"
@@ -11,6 +13,11 @@ import (
 	"github.com/snowfork/snowbridge/relayer/crypto/merkle"
 )
 
+// parallelEncodeThreshold is the minimum number of parachain heads
+// before SCALE-encoding is split across a worker pool. Below it, the
+// serial path is faster due to goroutine scheduling overhead.
+const parallelEncodeThreshold = 64
+
 // ByLeafIndex implements sort.Interface based on the LeafIndex field.
 type ByParaID []relaychain.ParaHead
 
@@ -79,22 +86,28 @@ func CreateParachainMerkleProof(heads map[uint32]relaychain.ParaHead, paraID uin
 	// sort slice by para ID
 	sort.Sort(ByParaID(headsAsSlice))
 
-	// loop headers, convert to pre leaves and find header being proven
-	preLeaves := make([][]byte, 0, len(headsAsSlice))
-	var headerToProve []byte
+	// find the index of the header being proven
 	var headerIndex int64
 	for i, head := range headsAsSlice {
-		preLeaf, err := types.EncodeToBytes(head)
-		if err != nil {
-			return MerkleProofData{}, err
-		}
-		preLeaves = append(preLeaves, preLeaf)
 		if head.ParaID == paraID {
-			headerToProve = preLeaf
 			headerIndex = int64(i)
 		}
 	}
 
+	// SCALE-encode every header into its pre-leaf, in parallel once the
+	// set is large enough to make it worthwhile
+	preLeaves := make([][]byte, len(headsAsSlice))
+	var err error
+	if len(headsAsSlice) >= parallelEncodeThreshold {
+		err = encodePreLeavesParallel(headsAsSlice, preLeaves)
+	} else {
+		err = encodePreLeaves(headsAsSlice, preLeaves)
+	}
+	if err != nil {
+		return MerkleProofData{}, err
+	}
+	headerToProve := preLeaves[headerIndex]
+
 	leaf, root, proof, err := merkle.GenerateMerkleProof(preLeaves, headerIndex)
 	if err != nil {
 		log.WithError(err).Error("Failed to create parachain header proof")
@@ -111,3 +124,62 @@ func CreateParachainMerkleProof(heads map[uint32]relaychain.ParaHead, paraID uin
 		Proof:           proof,
 	}, nil
 }
+
+// encodePreLeaves SCALE-encodes each head in order, writing the result to
+// its matching index in preLeaves.
+func encodePreLeaves(heads []relaychain.ParaHead, preLeaves [][]byte) error {
+	for i, head := range heads {
+		preLeaf, err := types.EncodeToBytes(head)
+		if err != nil {
+			return err
+		}
+		preLeaves[i] = preLeaf
+	}
+	return nil
+}
+
+// encodePreLeavesParallel SCALE-encodes heads across a worker pool
+// bounded by GOMAXPROCS, writing each result to its sorted index in
+// preLeaves so the output is identical to the serial path.
+func encodePreLeavesParallel(heads []relaychain.ParaHead, preLeaves [][]byte) error {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(heads) {
+		workers = len(heads)
+	}
+	chunk := (len(heads) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for w := 0; w < workers; w++ {
+		start := w * chunk
+		if start >= len(heads) {
+			break
+		}
+		end := start + chunk
+		if end > len(heads) {
+			end = len(heads)
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				preLeaf, err := types.EncodeToBytes(heads[i])
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+				preLeaves[i] = preLeaf
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+	return firstErr
+}
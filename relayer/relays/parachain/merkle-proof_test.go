@@ -0,0 +1,122 @@
+package parachain
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/snowfork/go-substrate-rpc-client/v3/types"
+	"github.com/snowfork/snowbridge/relayer/chain/relaychain"
+	"github.com/snowfork/snowbridge/relayer/crypto/merkle"
+)
+
+func benchmarkHeads(n int) map[uint32]relaychain.ParaHead {
+	heads := make(map[uint32]relaychain.ParaHead, n)
+	for i := 0; i < n; i++ {
+		paraID := uint32(i)
+		heads[paraID] = relaychain.ParaHead{
+			ParaID: paraID,
+			Data:   types.NewBytes(make([]byte, 256)),
+		}
+	}
+	return heads
+}
+
+// sortedParaHeads replicates the sort CreateParachainMerkleProof applies
+// internally, so a test can build its own serial reference output for
+// the same head set and proving index.
+func sortedParaHeads(heads map[uint32]relaychain.ParaHead) []relaychain.ParaHead {
+	sorted := make([]relaychain.ParaHead, 0, len(heads))
+	for _, head := range heads {
+		sorted = append(sorted, head)
+	}
+	sort.Sort(ByParaID(sorted))
+	return sorted
+}
+
+// TestCreateParachainMerkleProofSerialAndParallelPathsAreByteIdentical
+// builds a proof below parallelEncodeThreshold (the serial path), one
+// above it but still small (exercising only merkle.hashLeaves' parallel
+// branch), and one large enough that merkle.hashLayer's own parallel
+// branch also runs (a layer's pair count only reaches merkle's
+// parallelThreshold once the layer below it has at least 127 nodes), and
+// checks each against a reference built from the serial encodePreLeaves.
+// The reference leaf, root and proof come from merkle.GenerateMerkleProof
+// over the same number of pre-leaves, so they take the same serial or
+// parallel tree branches as the code under test: this compares the
+// pre-leaf encoding paths, not merkle's serial and parallel branches
+// against each other. On-chain verification depends on every path never
+// diverging.
+func TestCreateParachainMerkleProofSerialAndParallelPathsAreByteIdentical(t *testing.T) {
+	sizes := map[string]int{
+		"serial":               parallelEncodeThreshold - 1,
+		"parallel":             parallelEncodeThreshold + 50,
+		"parallel-tree-layers": parallelEncodeThreshold + 150,
+	}
+
+	for name, n := range sizes {
+		t.Run(name, func(t *testing.T) {
+			heads := benchmarkHeads(n)
+
+			got, err := CreateParachainMerkleProof(heads, 0)
+			if err != nil {
+				t.Fatalf("CreateParachainMerkleProof: %v", err)
+			}
+
+			ordered := sortedParaHeads(heads)
+			wantPreLeaves := make([][]byte, len(ordered))
+			if err := encodePreLeaves(ordered, wantPreLeaves); err != nil {
+				t.Fatalf("reference encodePreLeaves: %v", err)
+			}
+			if len(got.PreLeaves) != len(wantPreLeaves) {
+				t.Fatalf("got %d pre-leaves, want %d", len(got.PreLeaves), len(wantPreLeaves))
+			}
+			for i := range wantPreLeaves {
+				if !bytes.Equal(got.PreLeaves[i], wantPreLeaves[i]) {
+					t.Fatalf("pre-leaf %d diverged from the serial reference", i)
+				}
+			}
+
+			wantLeaf, wantRoot, wantProof, err := merkle.GenerateMerkleProof(wantPreLeaves, got.ProvenLeafIndex)
+			if err != nil {
+				t.Fatalf("reference GenerateMerkleProof: %v", err)
+			}
+			if !bytes.Equal(got.ProvenLeaf, wantLeaf) {
+				t.Fatalf("leaf diverged from the serial reference")
+			}
+			if !bytes.Equal(got.Root, wantRoot) {
+				t.Fatalf("root diverged from the serial reference")
+			}
+			if len(got.Proof) != len(wantProof) {
+				t.Fatalf("got proof of length %d, want %d", len(got.Proof), len(wantProof))
+			}
+			for i := range wantProof {
+				if got.Proof[i] != wantProof[i] {
+					t.Fatalf("proof element %d diverged from the serial reference", i)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCreateParachainMerkleProof_Serial(b *testing.B) {
+	heads := benchmarkHeads(parallelEncodeThreshold - 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := CreateParachainMerkleProof(heads, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateParachainMerkleProof_Parallel(b *testing.B) {
+	heads := benchmarkHeads(2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := CreateParachainMerkleProof(heads, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Snowfork
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package store
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Shutdown drains every command still queued on the database's command
+// channel, flushing each to the underlying store, then closes the
+// underlying sqlite handle. It returns once the channel is closed and
+// fully drained, or ctx is cancelled, whichever happens first.
+//
+// Callers must close the channel passed to NewDatabase once every
+// producer has stopped sending on it, before calling Shutdown. Treating
+// a momentarily-empty channel as fully drained (e.g. via a select
+// `default` branch) races a producer that is about to send: Shutdown has
+// no way to distinguish "nothing left to drain" from "nothing to drain
+// right this instant", so it must wait for the unambiguous signal that
+// close provides instead of guessing.
+func (da *Database) Shutdown(ctx context.Context) error {
+	da.logger.Info("Draining pending database commands")
+
+	drained := 0
+	for {
+		select {
+		case cmd, ok := <-da.messages:
+			if !ok {
+				log.WithField("drained", drained).Info("Drained all pending database commands")
+				return da.close()
+			}
+			if err := da.processCmd(cmd); err != nil {
+				log.WithError(err).Error("Failed to flush pending database command")
+			}
+			drained++
+		case <-ctx.Done():
+			log.WithFields(log.Fields{
+				"drained": drained,
+				"error":   ctx.Err(),
+			}).Warn("Database shutdown deadline exceeded while draining pending commands")
+			return da.close()
+		}
+	}
+}